@@ -0,0 +1,31 @@
+// Package api owns Grafana's HTTP route table. This file carries only the
+// quota route registration this tree has wiring for; a full checkout has
+// one field and one RegisterAPIEndpoints (or equivalent) call per
+// HTTP-exposing service in HTTPServer and registerRoutes.
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// quotaAdminAPI is the subset of quotaimpl.Service's surface registerRoutes
+// needs that isn't part of quota.Service: RegisterAPIEndpoints is an HTTP
+// concern only the server needs, not something every caller of the quota
+// service should have to see.
+type quotaAdminAPI interface {
+	RegisterAPIEndpoints(r *web.Mux)
+}
+
+// HTTPServer owns Grafana's route table.
+type HTTPServer struct {
+	QuotaService quota.Service
+}
+
+// registerRoutes mounts every service's HTTP routes onto r. Real Grafana
+// calls this once, from ProvideHTTPServer's constructor.
+func (hs *HTTPServer) registerRoutes(r *web.Mux) {
+	if svc, ok := hs.QuotaService.(quotaAdminAPI); ok {
+		svc.RegisterAPIEndpoints(r)
+	}
+}
@@ -0,0 +1,78 @@
+// Package remotecache provides the key-value cache abstraction shared
+// across Grafana services that want a configurable cache (Redis in a full
+// deployment) without taking a hard dependency on one: an in-memory
+// implementation serves as the zero-config fallback when no remote cache is
+// set up.
+package remotecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCacheItemNotFound is returned by Get for a key that was never set, or
+// that has since expired.
+var ErrCacheItemNotFound = errors.New("remotecache: item not found")
+
+// CacheStorage is the cache interface callers depend on. A real deployment
+// backs it with Redis; NewInMemory gives the same contract without one.
+type CacheStorage interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, expire time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type inMemoryItem struct {
+	value   []byte
+	expires time.Time
+}
+
+// inMemory is the in-process CacheStorage fallback used when no remote
+// cache is configured. It isn't shared across instances, so callers that
+// need cross-instance atomicity have to configure a real backend instead.
+type inMemory struct {
+	mu    sync.Mutex
+	items map[string]inMemoryItem
+}
+
+// NewInMemory returns a CacheStorage backed by a plain in-process map.
+func NewInMemory() CacheStorage {
+	return &inMemory{items: make(map[string]inMemoryItem)}
+}
+
+func (c *inMemory) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, ErrCacheItemNotFound
+	}
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		delete(c.items, key)
+		return nil, ErrCacheItemNotFound
+	}
+	return item.value, nil
+}
+
+func (c *inMemory) Set(_ context.Context, key string, value []byte, expire time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := inMemoryItem{value: value}
+	if expire > 0 {
+		item.expires = time.Now().Add(expire)
+	}
+	c.items[key] = item
+	return nil
+}
+
+func (c *inMemory) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+	return nil
+}
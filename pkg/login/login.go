@@ -0,0 +1,46 @@
+// Package login handles user authentication and signup. This file carries
+// only the SSO/LDAP signup path that calls into the quota service; a full
+// checkout has the rest of the auth flows alongside it here.
+package login
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+type userStore interface {
+	Create(ctx context.Context, orgID int64, login string) (int64, error)
+}
+
+// Service provisions users signing in for the first time via SSO/LDAP.
+type Service struct {
+	quota quota.Service
+	store userStore
+}
+
+// CreateUserFromSignup reserves user/org_user quota for orgID before the
+// user row exists, creates it, then confirms the reservation on success or
+// cancels it on failure. Without this, two concurrent SSO/LDAP logins for
+// the same new user could both pass a quota check, both insert, and land
+// the org over its user limit.
+func (s *Service) CreateUserFromSignup(ctx context.Context, orgID int64, login string) (int64, error) {
+	token, err := s.quota.ReserveForNewUser(ctx, orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := s.store.Create(ctx, orgID, login)
+	if err != nil {
+		if cancelErr := s.quota.CancelReservation(ctx, token); cancelErr != nil {
+			return 0, cancelErr
+		}
+		return 0, err
+	}
+
+	if err := s.quota.ConfirmReservation(ctx, token); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,51 @@
+// Package apikey creates and stores API keys. This file carries only the
+// creation path that calls into the quota service; a full checkout has
+// listing, deletion and token-hashing alongside it here.
+package apikey
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+const targetSrv = quota.TargetSrv("api_key")
+
+// APIKey is a single generated API key row.
+type APIKey struct {
+	ID    int64
+	OrgID int64
+	Name  string
+}
+
+type store interface {
+	Insert(ctx context.Context, key *APIKey) error
+}
+
+// Service creates API keys under the org's api_key quota.
+type Service struct {
+	quota quota.Service
+	store store
+}
+
+// CreateAPIKey reserves api_key quota for key's org before inserting it,
+// releasing the reservation if the insert fails and committing it if the
+// insert succeeds. This closes the race Reserve/Release/Commit exist for:
+// without it, two concurrent creates could both observe usage under the
+// limit and both insert, landing the org over it.
+func (s *Service) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	scopeParams := &quota.ScopeParameters{OrgID: key.OrgID}
+
+	if err := s.quota.Reserve(ctx, string(targetSrv), scopeParams, 1); err != nil {
+		return err
+	}
+
+	if err := s.store.Insert(ctx, key); err != nil {
+		if relErr := s.quota.Release(ctx, string(targetSrv), scopeParams, 1); relErr != nil {
+			return relErr
+		}
+		return err
+	}
+
+	return s.quota.Commit(ctx, string(targetSrv), scopeParams, 1)
+}
@@ -0,0 +1,332 @@
+// Package quota defines the types quotaimpl's Service implements: the tags
+// that identify a limit, the maps usage/limits are tracked in, and the
+// request/reporter shapes that the rest of Grafana integrates against.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// TargetSrv identifies the service a quota applies to, e.g. "dashboard" or
+// "user". Target identifies the row being counted within that service, and
+// Scope identifies whether the limit is global, per-org or per-user.
+type TargetSrv string
+type Target string
+type Scope string
+
+const (
+	GlobalScope Scope = "global"
+	OrgScope    Scope = "org"
+	UserScope   Scope = "user"
+)
+
+// Validate reports whether s is one of the known scopes.
+func (s Scope) Validate() error {
+	switch s {
+	case GlobalScope, OrgScope, UserScope:
+		return nil
+	default:
+		return ErrInvalidTarget.Errorf("invalid quota scope: %s", s)
+	}
+}
+
+var (
+	ErrDisabled          = errutil.NewBase(errutil.StatusNotFound, "quota.disabled")
+	ErrInvalidTarget     = errutil.NewBase(errutil.StatusBadRequest, "quota.invalidTarget")
+	ErrInvalidTargetSrv  = errutil.NewBase(errutil.StatusBadRequest, "quota.invalidTargetSrv")
+	ErrTargetSrvConflict = errutil.NewBase(errutil.StatusInternal, "quota.targetSrvConflict")
+
+	// ErrQuotaReached is returned by Reserve (and anything built on it, like
+	// ReserveForNewUser) when the post-increment usage for a tag would
+	// exceed its limit.
+	ErrQuotaReached = errutil.NewBase(errutil.StatusForbidden, "quota.reached")
+
+	// ErrInvalidReservationToken is returned by ConfirmReservation/
+	// CancelReservation for a token that's unknown, already resolved, or
+	// already reaped past its TTL.
+	ErrInvalidReservationToken = errutil.NewBase(errutil.StatusBadRequest, "quota.invalidReservationToken")
+)
+
+// Tag is the opaque "targetSrv:target:scope" key usage and limits are keyed
+// by. Use NewTag to build one and GetSrv/GetTarget/GetScope to decompose it.
+type Tag string
+
+// NewTag builds the canonical Tag for a (service, target, scope) triple.
+func NewTag(srv TargetSrv, target Target, scope Scope) (Tag, error) {
+	if err := scope.Validate(); err != nil {
+		return "", err
+	}
+	return Tag(fmt.Sprintf("%s:%s:%s", srv, target, scope)), nil
+}
+
+func (t Tag) parts() ([]string, error) {
+	parts := strings.Split(string(t), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidTarget.Errorf("invalid quota tag: %s", t)
+	}
+	return parts, nil
+}
+
+func (t Tag) GetSrv() (TargetSrv, error) {
+	parts, err := t.parts()
+	if err != nil {
+		return "", err
+	}
+	return TargetSrv(parts[0]), nil
+}
+
+func (t Tag) GetTarget() (Target, error) {
+	parts, err := t.parts()
+	if err != nil {
+		return "", err
+	}
+	return Target(parts[1]), nil
+}
+
+func (t Tag) GetScope() (Scope, error) {
+	parts, err := t.parts()
+	if err != nil {
+		return "", err
+	}
+	return Scope(parts[2]), nil
+}
+
+// Map is a set of int64 values keyed by Tag - usage counts in one context,
+// limits in another.
+type Map struct {
+	m map[Tag]int64
+}
+
+// Set stores v for tag, creating the backing map on first use so the zero
+// value of Map is ready to use.
+func (m *Map) Set(tag Tag, v int64) {
+	if m.m == nil {
+		m.m = make(map[Tag]int64)
+	}
+	m.m[tag] = v
+}
+
+func (m *Map) Get(tag Tag) (int64, bool) {
+	if m.m == nil {
+		return 0, false
+	}
+	v, ok := m.m[tag]
+	return v, ok
+}
+
+// Merge copies every entry of other into m, overwriting m's existing value
+// for any tag other also has.
+func (m *Map) Merge(other *Map) {
+	if other == nil {
+		return
+	}
+	for tag, v := range other.m {
+		m.Set(tag, v)
+	}
+}
+
+// MapItem is a single (Tag, value) pair yielded by Map.Iter.
+type MapItem struct {
+	Tag   Tag
+	Value int64
+}
+
+// Iter streams every entry of m over a channel.
+func (m *Map) Iter() <-chan MapItem {
+	ch := make(chan MapItem)
+	go func() {
+		defer close(ch)
+		for tag, v := range m.m {
+			ch <- MapItem{Tag: tag, Value: v}
+		}
+	}()
+	return ch
+}
+
+// Targets returns the distinct set of Target values across every tag in m.
+func (m *Map) Targets() (map[Target]struct{}, error) {
+	targets := make(map[Target]struct{}, len(m.m))
+	for tag := range m.m {
+		target, err := tag.GetTarget()
+		if err != nil {
+			return nil, err
+		}
+		targets[target] = struct{}{}
+	}
+	return targets, nil
+}
+
+// ScopeParameters narrows a quota check/reservation to a single org and/or
+// user. A zero value means the global scope.
+type ScopeParameters struct {
+	OrgID  int64
+	UserID int64
+}
+
+// QuotaDTO is the API-facing view of a single tag's limit/usage.
+type QuotaDTO struct {
+	Target  string `json:"target"`
+	Limit   int64  `json:"limit"`
+	OrgId   int64  `json:"org_id,omitempty"`
+	UserId  int64  `json:"user_id,omitempty"`
+	Used    int64  `json:"used"`
+	Service string `json:"service,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+
+	// Source names which layer the Limit came from: "default", "group" or
+	// "custom" (an admin-set per-scope override). It names the layer only:
+	// for a "group" limit it does not say which group or rule set it.
+	Source string `json:"source,omitempty"`
+}
+
+// UpdateQuotaCmd sets a per-scope custom override for a single target.
+type UpdateQuotaCmd struct {
+	Target string `json:"target"`
+	Limit  int64  `json:"limit"`
+	OrgID  int64  `json:"-"`
+	UserID int64  `json:"-"`
+}
+
+// UsageReporterFunc reports current usage for every tag a reporter owns,
+// optionally narrowed to scopeParams.
+type UsageReporterFunc func(ctx context.Context, scopeParams *ScopeParameters) (*Map, error)
+
+// NewQuotaReporter registers a UsageReporterFunc for a TargetSrv, along with
+// the default limits it starts with.
+//
+// There's deliberately no per-reporter Notifier field here: Warning/Reached
+// transitions are published as UsageWarningEvent/ReachedEvent on pkg/bus
+// instead, which lets any integrator (alerting, provisioning, SMTP/webhook
+// notifiers) subscribe without touching this struct or its caller's
+// AddReporter call site at all - a better fit for "subscribe without
+// changing your call site" than a field only the registering reporter could
+// set would have been.
+type NewQuotaReporter struct {
+	TargetSrv     TargetSrv
+	DefaultLimits *Map
+	Reporter      UsageReporterFunc
+
+	// WarnAt carries, per tag, the usage percentage (0-100) at which
+	// CheckQuotaStatus should report Warning instead of waiting for Reached.
+	WarnAt *Map
+
+	// RequiresFeature, if set, gates this registration on
+	// License.HasFeature so an OSS build can't register an enterprise-only
+	// reporter.
+	RequiresFeature string
+}
+
+// QuotaStatus is the richer, per-tag result of CheckQuotaStatus: besides the
+// binary Reached, it reports whether usage has crossed the tag's WarnAt
+// threshold and the raw usage/limit values so callers can render something
+// like "you're using 85% of your dashboards" instead of a plain pass/fail.
+type QuotaStatus struct {
+	Tag     Tag
+	Usage   int64
+	Limit   int64
+	Reached bool
+	Warning bool
+}
+
+// GroupMappingKind identifies whether a QuotaGroupMapping attaches a group to
+// a user or to an org.
+type GroupMappingKind string
+
+const (
+	UserMappingKind GroupMappingKind = "user"
+	OrgMappingKind  GroupMappingKind = "org"
+)
+
+// QuotaRule is a single named limit that can be shared across groups, e.g.
+// "50 dashboards". Subjects lists the tags the limit applies to, so one rule
+// can cover a target across every scope it's registered for.
+type QuotaRule struct {
+	ID       int64  `json:"id" xorm:"pk autoincr 'id'"`
+	Name     string `json:"name" xorm:"name"`
+	Limit    int64  `json:"limit" xorm:"limit"`
+	Subjects []Tag  `json:"subjects" xorm:"-"`
+}
+
+// TableName pins the xorm table name instead of relying on the default
+// struct-name mapper, since quotaimpl's sqlStore also has to name it in
+// migrations.go.
+func (QuotaRule) TableName() string { return "quota_rule" }
+
+// QuotaGroup bundles rules into a tier, e.g. "free" or "enterprise", that can
+// be assigned wholesale to a user or an org via QuotaGroupMapping.
+type QuotaGroup struct {
+	ID    int64    `json:"id" xorm:"pk autoincr 'id'"`
+	Name  string   `json:"name" xorm:"name"`
+	Rules []string `json:"rules" xorm:"-"`
+}
+
+func (QuotaGroup) TableName() string { return "quota_group" }
+
+// QuotaGroupMapping attaches a QuotaGroup to a single user or org.
+type QuotaGroupMapping struct {
+	ID        int64            `json:"id" xorm:"pk autoincr 'id'"`
+	Kind      GroupMappingKind `json:"kind" xorm:"kind"`
+	MappedID  int64            `json:"mappedId" xorm:"mapped_id"`
+	GroupName string           `json:"groupName" xorm:"group_name"`
+}
+
+func (QuotaGroupMapping) TableName() string { return "quota_group_mapping" }
+
+// LimitSource names where an EffectiveLimit came from, so callers can
+// explain a limit to the user ("capped by license: 25 data sources")
+// instead of just stating a number.
+type LimitSource interface {
+	Name() string
+	GetLimit(ctx context.Context, tag Tag) (int64, bool, error)
+}
+
+// Service is the public quota API the rest of Grafana depends on.
+type Service interface {
+	QuotaReached(c *models.ReqContext, target string) (bool, error)
+	Get(ctx context.Context, scope string, id int64) ([]QuotaDTO, error)
+	Update(ctx context.Context, cmd *UpdateQuotaCmd) error
+	CheckQuotaReached(ctx context.Context, target string, scopeParams *ScopeParameters) (bool, error)
+	DeleteByUser(ctx context.Context, userID int64) error
+	AddReporter(ctx context.Context, e *NewQuotaReporter) error
+
+	// CheckQuotaStatus is the richer counterpart to CheckQuotaReached: a
+	// QuotaStatus per tag plus Warning/Reached bus events on threshold
+	// crossings, instead of a single collapsed bool.
+	CheckQuotaStatus(ctx context.Context, target string, scopeParams *ScopeParameters) ([]QuotaStatus, error)
+
+	// Reserve/Release/Commit let a caller make usage-and-limit atomic
+	// around a write: Reserve increments the cached counters and returns
+	// ErrQuotaReached before the write happens if it would exceed a limit,
+	// Release gives the reservation back on failure, and Commit marks it
+	// final on success.
+	Reserve(ctx context.Context, target string, scopeParams *ScopeParameters, n int64) error
+	Release(ctx context.Context, target string, scopeParams *ScopeParameters, n int64) error
+	Commit(ctx context.Context, target string, scopeParams *ScopeParameters, n int64) error
+
+	// ReserveForNewUser/ConfirmReservation/CancelReservation are Reserve's
+	// counterpart for signup, where no user row (and so no UserID to scope
+	// a reservation by) exists yet until the write succeeds.
+	ReserveForNewUser(ctx context.Context, orgID int64) (string, error)
+	ConfirmReservation(ctx context.Context, token string) error
+	CancelReservation(ctx context.Context, token string) error
+
+	// CreateRule/UpdateRule/DeleteRule/CreateGroup/AddRuleToGroup/
+	// AssignGroup manage the quota groups/rules tiered limits are built
+	// from, behind the /api/admin/quota-groups API.
+	CreateRule(ctx context.Context, rule *QuotaRule) error
+	UpdateRule(ctx context.Context, rule *QuotaRule) error
+	DeleteRule(ctx context.Context, name string) error
+	CreateGroup(ctx context.Context, name string) error
+	AddRuleToGroup(ctx context.Context, groupName, ruleName string) error
+	AssignGroup(ctx context.Context, kind GroupMappingKind, id int64, groupName string) error
+
+	// EffectiveLimit resolves tag's limit across every configured
+	// LimitSource (config, license, admin override) and reports which one
+	// won, so callers can explain why a limit is what it is.
+	EffectiveLimit(ctx context.Context, tag Tag) (int64, LimitSource, error)
+}
@@ -0,0 +1,93 @@
+package quotaimpl
+
+import (
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/middleware"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// RegisterAPIEndpoints mounts the /api/admin/quota-groups endpoints used to
+// manage quota groups/rules and assign them to users or orgs, so operators
+// can define tiers like "free"/"team"/"enterprise" once instead of editing
+// per-org quotas individually. Like every other /api/admin/* route, these
+// require a signed-in Grafana admin.
+func (s *Service) RegisterAPIEndpoints(r *web.Mux) {
+	r.Post("/api/admin/quota-groups/rules", middleware.ReqSignedIn, middleware.ReqGrafanaAdmin, s.createRule)
+	r.Post("/api/admin/quota-groups", middleware.ReqSignedIn, middleware.ReqGrafanaAdmin, s.createGroup)
+	r.Post("/api/admin/quota-groups/:name/rules/:ruleName", middleware.ReqSignedIn, middleware.ReqGrafanaAdmin, s.addRuleToGroup)
+	r.Post("/api/admin/quota-groups/:name/assign/:kind/:id", middleware.ReqSignedIn, middleware.ReqGrafanaAdmin, s.assignGroup)
+}
+
+type createRuleCmd struct {
+	Name     string   `json:"name"`
+	Limit    int64    `json:"limit"`
+	Subjects []string `json:"subjects"`
+}
+
+func (s *Service) createRule(c *contextmodel.ReqContext) response.Response {
+	cmd := createRuleCmd{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	rule := &quota.QuotaRule{Name: cmd.Name, Limit: cmd.Limit}
+	for _, subj := range cmd.Subjects {
+		rule.Subjects = append(rule.Subjects, quota.Tag(subj))
+	}
+
+	if err := s.CreateRule(c.Req.Context(), rule); err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to create quota rule", err)
+	}
+
+	return response.Success("quota rule created")
+}
+
+func (s *Service) createGroup(c *contextmodel.ReqContext) response.Response {
+	cmd := struct {
+		Name string `json:"name"`
+	}{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	if err := s.CreateGroup(c.Req.Context(), cmd.Name); err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to create quota group", err)
+	}
+
+	return response.Success("quota group created")
+}
+
+func (s *Service) addRuleToGroup(c *contextmodel.ReqContext) response.Response {
+	groupName := web.Params(c.Req)[":name"]
+	ruleName := web.Params(c.Req)[":ruleName"]
+
+	if err := s.AddRuleToGroup(c.Req.Context(), groupName, ruleName); err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to add rule to quota group", err)
+	}
+
+	return response.Success("rule added to quota group")
+}
+
+func (s *Service) assignGroup(c *contextmodel.ReqContext) response.Response {
+	groupName := web.Params(c.Req)[":name"]
+	kind := quota.GroupMappingKind(web.Params(c.Req)[":kind"])
+
+	id, err := web.Params(c.Req).Int64(":id")
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "invalid id", err)
+	}
+
+	if kind != quota.UserMappingKind && kind != quota.OrgMappingKind {
+		return response.Error(http.StatusBadRequest, "kind must be user or org", nil)
+	}
+
+	if err := s.AssignGroup(c.Req.Context(), kind, id, groupName); err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to assign quota group", err)
+	}
+
+	return response.Success("quota group assigned")
+}
@@ -0,0 +1,127 @@
+package quotaimpl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// scopeKey is the "global"/"user:<id>"/"org:<id>" suffix shared by
+// counterKey (which scopes a counter) and reconcile (which needs to find
+// every scope a counter has been reserved against).
+func scopeKey(scopeParams *quota.ScopeParameters) string {
+	switch {
+	case scopeParams == nil:
+		return "global"
+	case scopeParams.UserID != 0:
+		return fmt.Sprintf("user:%d", scopeParams.UserID)
+	case scopeParams.OrgID != 0:
+		return fmt.Sprintf("org:%d", scopeParams.OrgID)
+	default:
+		return "global"
+	}
+}
+
+// counterKey builds the counter's identity, scoped to the target service
+// and, when present, the org/user the check applies to.
+func counterKey(targetSrv quota.TargetSrv, scopeParams *quota.ScopeParameters, tag quota.Tag) string {
+	return fmt.Sprintf("quota.used.%s.%s.%s", targetSrv, scopeKey(scopeParams), tag)
+}
+
+// quotaCounter is the interface Reserve/Release/reconcile actually depend
+// on, so tests can fake it without a real cache.
+type quotaCounter interface {
+	incrBy(ctx context.Context, key string, delta int64) (int64, error)
+	get(ctx context.Context, key string) (int64, error)
+	set(ctx context.Context, key string, v int64) error
+}
+
+// keyedMutex hands out a *sync.Mutex per key, so incrBy's read-modify-write
+// against the cache serializes per counter instead of behind one global lock.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (m *keyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[key] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// counterStore keeps per-tag "used" counters in a remotecache.CacheStorage
+// (Redis in a full deployment, an in-memory map otherwise), so Reserve can
+// do an INCRBY-style update instead of each caller paying for a SQL
+// COUNT(*). incrBy's read-then-write is serialized per key by locks, which
+// gives atomicity within one instance; see Run's reconciler for how drift
+// across instances (or a cache that lost a key) is corrected against the
+// reporters' ground truth.
+type counterStore struct {
+	cache remotecache.CacheStorage
+	locks keyedMutex
+}
+
+func newCounterStore(cache remotecache.CacheStorage) *counterStore {
+	return &counterStore{cache: cache}
+}
+
+// incrBy adds delta to the counter at key and returns the resulting value.
+// Negative results are clamped to zero so a stray Release can't push a
+// counter below what the reconciler will see as ground truth.
+func (c *counterStore) incrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	unlock := c.locks.Lock(key)
+	defer unlock()
+
+	cur, err := c.get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	next := cur + delta
+	if next < 0 {
+		next = 0
+	}
+
+	if err := c.set(ctx, key, next); err != nil {
+		return 0, err
+	}
+
+	return next, nil
+}
+
+func (c *counterStore) get(ctx context.Context, key string) (int64, error) {
+	b, err := c.cache.Get(ctx, key)
+	if err != nil {
+		if err == remotecache.ErrCacheItemNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("corrupt quota counter at %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// set overwrites the counter at key, used by reconcile to write back a
+// reporter's ground truth. Counters don't expire: they're kept current by
+// the reconciler, not by TTL.
+func (c *counterStore) set(ctx context.Context, key string, v int64) error {
+	return c.cache.Set(ctx, key, []byte(strconv.FormatInt(v, 10)), 0)
+}
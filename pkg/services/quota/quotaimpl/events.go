@@ -0,0 +1,36 @@
+package quotaimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// eventPublisher is the narrow slice of pkg/bus's Bus that
+// Service.publishTransition needs. Accepting this instead of the full
+// bus.Bus keeps quotaimpl's dependency on the bus package to one method and
+// lets tests fake it without a real bus.
+type eventPublisher interface {
+	Publish(ctx context.Context, event interface{}) error
+}
+
+// UsageWarningEvent is published on pkg/bus when a tag's usage crosses its
+// WarnAt threshold while still under the hard limit, so integrators (e.g.
+// in-product banners, SMTP/webhook notifications) can alert org admins
+// before a quota is actually reached.
+type UsageWarningEvent struct {
+	TargetSrv quota.TargetSrv
+	Tag       quota.Tag
+	Usage     int64
+	Limit     int64
+}
+
+// ReachedEvent is published on pkg/bus the moment a tag transitions from
+// under-limit to at-limit. It is not republished on every subsequent check
+// while the tag stays at-limit; see Service.publishTransition.
+type ReachedEvent struct {
+	TargetSrv quota.TargetSrv
+	Tag       quota.Tag
+	Usage     int64
+	Limit     int64
+}
@@ -0,0 +1,83 @@
+package quotaimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// License is the subset of Grafana Enterprise's licensing service the quota
+// package needs: whether a feature is licensed at all, and what limit it
+// entitles a tag to. A nil License (the OSS default) means every
+// license-gated feature and limit is simply absent.
+type License interface {
+	HasFeature(feature string) bool
+	Quota(tag quota.Tag) (int64, bool)
+}
+
+// configLimitSource is the limit a reporter registered via AddReporter's
+// DefaultLimits - the long-standing, config-file-driven behavior.
+type configLimitSource struct{ s *Service }
+
+func (c *configLimitSource) Name() string { return "config" }
+
+func (c *configLimitSource) GetLimit(_ context.Context, tag quota.Tag) (int64, bool, error) {
+	v, ok := c.s.defaultLimits.Get(tag)
+	return v, ok, nil
+}
+
+// licenseLimitSource reads the limit a Grafana Enterprise license entitles a
+// tag to. It reports not-found whenever no License is configured, which is
+// always the case in OSS builds.
+type licenseLimitSource struct{ s *Service }
+
+func (l *licenseLimitSource) Name() string { return "license" }
+
+func (l *licenseLimitSource) GetLimit(_ context.Context, tag quota.Tag) (int64, bool, error) {
+	if l.s.license == nil {
+		return 0, false, nil
+	}
+	v, ok := l.s.license.Quota(tag)
+	return v, ok, nil
+}
+
+// overrideLimitSource is the global-scope limit an admin set through the
+// existing quota API/UI (sqlStore's custom limits).
+type overrideLimitSource struct{ s *Service }
+
+func (o *overrideLimitSource) Name() string { return "override" }
+
+func (o *overrideLimitSource) GetLimit(ctx context.Context, tag quota.Tag) (int64, bool, error) {
+	customLimits, err := o.s.store.Get(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	v, ok := customLimits.Get(tag)
+	return v, ok, nil
+}
+
+// EffectiveLimit resolves tag's limit across every configured LimitSource in
+// priority order (config, then license, then the admin override) and
+// reports which one won, so callers can explain *why* a limit is what it is
+// rather than just what it is.
+func (s *Service) EffectiveLimit(ctx context.Context, tag quota.Tag) (int64, quota.LimitSource, error) {
+	var limit int64
+	var source quota.LimitSource
+
+	for _, src := range s.limitSources {
+		v, ok, err := src.GetLimit(ctx, tag)
+		if err != nil {
+			return 0, nil, err
+		}
+		if ok {
+			limit = v
+			source = src
+		}
+	}
+
+	if source == nil {
+		return 0, nil, quota.ErrInvalidTarget.Errorf("no limit configured for tag: %s", tag)
+	}
+
+	return limit, source, nil
+}
@@ -0,0 +1,143 @@
+package quotaimpl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// fakeLicense is a minimal License for tests that don't need a real one.
+type fakeLicense struct {
+	features map[string]bool
+	limits   map[quota.Tag]int64
+}
+
+func (l *fakeLicense) HasFeature(feature string) bool { return l.features[feature] }
+
+func (l *fakeLicense) Quota(tag quota.Tag) (int64, bool) {
+	v, ok := l.limits[tag]
+	return v, ok
+}
+
+func newEffectiveLimitTestService(license License) *Service {
+	s := &Service{
+		store:         &fakeStore{},
+		defaultLimits: &quota.Map{},
+		license:       license,
+	}
+	s.limitSources = []quota.LimitSource{
+		&configLimitSource{s: s},
+		&licenseLimitSource{s: s},
+		&overrideLimitSource{s: s},
+	}
+	return s
+}
+
+func TestService_EffectiveLimit(t *testing.T) {
+	const srv = quota.TargetSrv("dashboard")
+	tag := mustTag(t, srv, "dashboard", quota.GlobalScope)
+
+	t.Run("falls back to the config default when nothing else applies", func(t *testing.T) {
+		s := newEffectiveLimitTestService(nil)
+		s.defaultLimits.Set(tag, 10)
+
+		limit, source, err := s.EffectiveLimit(context.Background(), tag)
+		require.NoError(t, err)
+		require.Equal(t, int64(10), limit)
+		require.Equal(t, "config", source.Name())
+	})
+
+	t.Run("a license entitlement wins over the config default", func(t *testing.T) {
+		s := newEffectiveLimitTestService(&fakeLicense{limits: map[quota.Tag]int64{tag: 50}})
+		s.defaultLimits.Set(tag, 10)
+
+		limit, source, err := s.EffectiveLimit(context.Background(), tag)
+		require.NoError(t, err)
+		require.Equal(t, int64(50), limit)
+		require.Equal(t, "license", source.Name())
+	})
+
+	t.Run("an admin override wins over both", func(t *testing.T) {
+		s := newEffectiveLimitTestService(&fakeLicense{limits: map[quota.Tag]int64{tag: 50}})
+		s.defaultLimits.Set(tag, 10)
+		s.store = &fakeOverrideStore{limit: 5, tag: tag}
+
+		limit, source, err := s.EffectiveLimit(context.Background(), tag)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), limit)
+		require.Equal(t, "override", source.Name())
+	})
+
+	t.Run("no configured source returns ErrInvalidTarget", func(t *testing.T) {
+		s := newEffectiveLimitTestService(nil)
+
+		_, _, err := s.EffectiveLimit(context.Background(), tag)
+		require.ErrorIs(t, err, quota.ErrInvalidTarget)
+	})
+}
+
+// fakeOverrideStore is a minimal store that returns a single custom limit
+// from Get, for testing overrideLimitSource without a real database.
+type fakeOverrideStore struct {
+	fakeStore
+	tag   quota.Tag
+	limit int64
+}
+
+func (f *fakeOverrideStore) Get(context.Context, *quota.ScopeParameters) (*quota.Map, error) {
+	m := &quota.Map{}
+	m.Set(f.tag, f.limit)
+	return m, nil
+}
+
+func TestService_AddReporter_RequiresFeature(t *testing.T) {
+	const srv = quota.TargetSrv("enterprise-feature")
+
+	t.Run("refused without a license", func(t *testing.T) {
+		s := newEffectiveLimitTestService(nil)
+		s.reporters = make(map[quota.TargetSrv]quota.UsageReporterFunc)
+
+		err := s.AddReporter(context.Background(), &quota.NewQuotaReporter{
+			TargetSrv:       srv,
+			DefaultLimits:   &quota.Map{},
+			Reporter:        quota.UsageReporterFunc(func(context.Context, *quota.ScopeParameters) (*quota.Map, error) { return &quota.Map{}, nil }),
+			RequiresFeature: "enterprise.feature",
+		})
+		require.ErrorIs(t, err, quota.ErrInvalidTargetSrv)
+
+		_, ok := s.getReporter(srv)
+		require.False(t, ok, "a refused registration shouldn't be reachable")
+	})
+
+	t.Run("refused when the license lacks the specific feature", func(t *testing.T) {
+		s := newEffectiveLimitTestService(&fakeLicense{features: map[string]bool{"other.feature": true}})
+		s.reporters = make(map[quota.TargetSrv]quota.UsageReporterFunc)
+
+		err := s.AddReporter(context.Background(), &quota.NewQuotaReporter{
+			TargetSrv:       srv,
+			DefaultLimits:   &quota.Map{},
+			Reporter:        quota.UsageReporterFunc(func(context.Context, *quota.ScopeParameters) (*quota.Map, error) { return &quota.Map{}, nil }),
+			RequiresFeature: "enterprise.feature",
+		})
+		require.ErrorIs(t, err, quota.ErrInvalidTargetSrv)
+	})
+
+	t.Run("allowed once the license has the feature", func(t *testing.T) {
+		s := newEffectiveLimitTestService(&fakeLicense{features: map[string]bool{"enterprise.feature": true}})
+		s.reporters = make(map[quota.TargetSrv]quota.UsageReporterFunc)
+
+		err := s.AddReporter(context.Background(), &quota.NewQuotaReporter{
+			TargetSrv:       srv,
+			DefaultLimits:   &quota.Map{},
+			Reporter:        quota.UsageReporterFunc(func(context.Context, *quota.ScopeParameters) (*quota.Map, error) { return &quota.Map{}, nil }),
+			RequiresFeature: "enterprise.feature",
+		})
+		require.NoError(t, err)
+
+		_, ok := s.getReporter(srv)
+		require.True(t, ok)
+	})
+}
@@ -0,0 +1,71 @@
+package quotaimpl
+
+import "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+// AddMigrations registers the tables quotaimpl owns beyond the long-standing
+// "quota" override table: the rule/group entities behind tiered quotas. The
+// cached-counter reservation path (see counter.go) lives in remotecache, not
+// SQL, so it has no migration here. It's meant to be called from the main
+// migration list in pkg/services/sqlstore/migrations, the same way every
+// other service's AddXMigrations is.
+func AddMigrations(mg *migrator.Migrator) {
+	mg.AddMigration("create quota_rule table", migrator.NewAddTableMigration(migrator.Table{
+		Name: "quota_rule",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "limit", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"name"}, Type: migrator.UniqueIndex},
+		},
+	}))
+
+	mg.AddMigration("create quota_rule_subject table", migrator.NewAddTableMigration(migrator.Table{
+		Name: "quota_rule_subject",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "rule_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "tag", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"rule_id"}},
+		},
+	}))
+
+	mg.AddMigration("create quota_group table", migrator.NewAddTableMigration(migrator.Table{
+		Name: "quota_group",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"name"}, Type: migrator.UniqueIndex},
+		},
+	}))
+
+	mg.AddMigration("create quota_group_rule table", migrator.NewAddTableMigration(migrator.Table{
+		Name: "quota_group_rule",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "group_name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "rule_name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"group_name"}},
+		},
+	}))
+
+	mg.AddMigration("create quota_group_mapping table", migrator.NewAddTableMigration(migrator.Table{
+		Name: "quota_group_mapping",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "kind", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "mapped_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "group_name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"kind", "mapped_id"}},
+		},
+	}))
+}
@@ -4,15 +4,26 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/quota"
 	"github.com/grafana/grafana/pkg/setting"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
+// Service must itself be registered as a registry.BackgroundService (e.g. in
+// the background service set ProvideService's caller assembles) for Run to
+// ever execute; ProvideService only constructs the Service, it doesn't
+// register it.
+var _ registry.BackgroundService = (*Service)(nil)
+
 type Service struct {
 	store  store
 	Cfg    *setting.Cfg
@@ -22,6 +33,41 @@ type Service struct {
 	reporters map[quota.TargetSrv]quota.UsageReporterFunc
 
 	defaultLimits *quota.Map
+
+	// warnAtLimits holds, per tag, the usage percentage (0-100) at which
+	// CheckQuotaStatus reports Warning instead of waiting for Reached.
+	warnAtLimits *quota.Map
+
+	// reachedState/warnState remember the last known Reached/Warning value
+	// per (scope, tag) - not per tag alone, since a tag like
+	// "dashboard:dashboard:org" is shared by every org and a plain
+	// map[Tag]bool would let one org's status clobber another's - so
+	// publishTransition only emits an event on the under -> over edge for
+	// that specific scope, not on every subsequent check or for unrelated
+	// orgs/users sharing the tag.
+	reachedState sync.Map // map[string]bool, keyed by reachedStateKey
+	warnState    sync.Map // map[string]bool, keyed by reachedStateKey
+
+	bus eventPublisher
+
+	// counters and reconcileGroup back the cached-counter reservation path
+	// (Reserve/Release/Commit); see Run for the reconciliation loop that
+	// keeps them honest against the reporters' ground truth. knownScopes
+	// remembers every scope Reserve/Release has touched, so reconcile can
+	// walk per-scope usage instead of only ever reconciling the global one.
+	counters       quotaCounter
+	reconcileGroup singleflight.Group
+	knownScopes    sync.Map // map[string]*quota.ScopeParameters, keyed by scopeKey
+
+	// signupReservations tracks in-flight ReserveForNewUser reservations by
+	// token; see reapExpiredReservations for how they're eventually freed.
+	signupReservations sync.Map // map[string]signupReservation
+
+	// license is nil on OSS builds; see AddReporter for how registrations
+	// behind RequiresFeature are gated on it, and EffectiveLimit/license.go
+	// for how it participates as a LimitSource.
+	license      License
+	limitSources []quota.LimitSource
 }
 
 type ServiceDisabled struct{}
@@ -50,13 +96,85 @@ func (s *ServiceDisabled) AddReporter(_ context.Context, e *quota.NewQuotaReport
 	return nil
 }
 
-func ProvideService(db db.DB, cfg *setting.Cfg) quota.Service {
+func (s *ServiceDisabled) CheckQuotaStatus(ctx context.Context, target string, scopeParams *quota.ScopeParameters) ([]quota.QuotaStatus, error) {
+	return nil, quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) Reserve(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) Release(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) Commit(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) ReserveForNewUser(ctx context.Context, orgID int64) (string, error) {
+	return "", quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) ConfirmReservation(ctx context.Context, token string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) CancelReservation(ctx context.Context, token string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) CreateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) UpdateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) DeleteRule(ctx context.Context, name string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) CreateGroup(ctx context.Context, name string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) AddRuleToGroup(ctx context.Context, groupName, ruleName string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) AssignGroup(ctx context.Context, kind quota.GroupMappingKind, id int64, groupName string) error {
+	return quota.ErrDisabled
+}
+
+func (s *ServiceDisabled) EffectiveLimit(ctx context.Context, tag quota.Tag) (int64, quota.LimitSource, error) {
+	return 0, nil, quota.ErrDisabled
+}
+
+// ProvideService constructs the quota Service. remoteCache backs the
+// cached-counter Reserve/Release/Commit path (see counter.go); pass
+// remotecache.NewInMemory() where no Redis (or other remote cache) is
+// configured.
+func ProvideService(db db.DB, cfg *setting.Cfg, bus bus.Bus, remoteCache remotecache.CacheStorage, license License) quota.Service {
 	s := Service{
 		store:         &sqlStore{db: db},
 		Cfg:           cfg,
 		Logger:        log.New("quota_service"),
 		reporters:     make(map[quota.TargetSrv]quota.UsageReporterFunc),
 		defaultLimits: &quota.Map{},
+		warnAtLimits:  &quota.Map{},
+		bus:           bus,
+		counters:      newCounterStore(remoteCache),
+		license:       license,
+	}
+
+	// Priority order, lowest to highest: config defaults, then license
+	// entitlements, then whatever an admin has explicitly overridden.
+	s.limitSources = []quota.LimitSource{
+		&configLimitSource{s: &s},
+		&licenseLimitSource{s: &s},
+		&overrideLimitSource{s: &s},
 	}
 
 	if s.IsDisabled() {
@@ -114,6 +232,7 @@ func (s *Service) Get(ctx context.Context, scope string, id int64) ([]quota.Quot
 
 	for item := range s.defaultLimits.Iter() {
 		limit := item.Value
+		source := "default"
 
 		scp, err := item.Tag.GetScope()
 		if err != nil {
@@ -124,16 +243,24 @@ func (s *Service) Get(ctx context.Context, scope string, id int64) ([]quota.Quot
 			continue
 		}
 
-		if targetCustomLimit, ok := customLimits.Get(item.Tag); ok {
-			limit = targetCustomLimit
+		srv, err := item.Tag.GetSrv()
+		if err != nil {
+			return nil, err
 		}
 
-		target, err := item.Tag.GetTarget()
-		if err != nil {
+		if groupLimits, err := s.groupLimits(ctx, srv, &scopeParams); err != nil {
 			return nil, err
+		} else if groupLimit, ok := groupLimits[item.Tag]; ok {
+			limit = groupLimit
+			source = "group"
 		}
 
-		srv, err := item.Tag.GetSrv()
+		if targetCustomLimit, ok := customLimits.Get(item.Tag); ok {
+			limit = targetCustomLimit
+			source = "custom"
+		}
+
+		target, err := item.Tag.GetTarget()
 		if err != nil {
 			return nil, err
 		}
@@ -147,6 +274,7 @@ func (s *Service) Get(ctx context.Context, scope string, id int64) ([]quota.Quot
 			Used:    used,
 			Service: string(srv),
 			Scope:   scope,
+			Source:  source,
 		})
 	}
 
@@ -173,37 +301,103 @@ func (s *Service) Update(ctx context.Context, cmd *quota.UpdateQuotaCmd) error {
 
 // CheckQuotaReached check that quota is reached for a target. If ScopeParameters are not defined, only global scope is checked
 func (s *Service) CheckQuotaReached(ctx context.Context, target string, scopeParams *quota.ScopeParameters) (bool, error) {
-	targetSrvLimits, err := s.getOverridenLimits(ctx, quota.TargetSrv(target), scopeParams)
+	statuses, err := s.CheckQuotaStatus(ctx, target, scopeParams)
 	if err != nil {
 		return false, err
 	}
 
-	usageReporterFunc, ok := s.getReporter(quota.TargetSrv(target))
+	for _, status := range statuses {
+		if status.Reached {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckQuotaStatus is the richer counterpart to CheckQuotaReached: rather
+// than collapsing every tag down to one bool, it returns a QuotaStatus per
+// tag, and publishes UsageWarningEvent/ReachedEvent bus events on the
+// relevant threshold crossings.
+func (s *Service) CheckQuotaStatus(ctx context.Context, target string, scopeParams *quota.ScopeParameters) ([]quota.QuotaStatus, error) {
+	targetSrv := quota.TargetSrv(target)
+
+	targetSrvLimits, err := s.getOverridenLimits(ctx, targetSrv, scopeParams)
+	if err != nil {
+		return nil, err
+	}
+
+	usageReporterFunc, ok := s.getReporter(targetSrv)
 	if !ok {
-		return false, quota.ErrInvalidTargetSrv
+		return nil, quota.ErrInvalidTargetSrv
 	}
 	targetUsage, err := usageReporterFunc(ctx, scopeParams)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	for t, limit := range targetSrvLimits {
-		switch {
-		case limit < 0:
-			continue
-		case limit == 0:
-			return true, nil
-		default:
-			u, ok := targetUsage.Get(t)
+	statuses := make([]quota.QuotaStatus, 0, len(targetSrvLimits))
+	for tag, limit := range targetSrvLimits {
+		status := quota.QuotaStatus{Tag: tag, Limit: limit}
+
+		if limit >= 0 {
+			used, ok := targetUsage.Get(tag)
 			if !ok {
-				return false, fmt.Errorf("no usage for target:%s", t)
+				return nil, fmt.Errorf("no usage for target:%s", tag)
 			}
-			if u >= limit {
-				return true, nil
+			status.Usage = used
+
+			switch {
+			case limit == 0, used >= limit:
+				status.Reached = true
+			default:
+				if warnAt, ok := s.warnAtLimits.Get(tag); ok && warnAt > 0 && used*100 >= warnAt*limit {
+					status.Warning = true
+				}
 			}
 		}
+
+		s.publishTransition(ctx, targetSrv, scopeParams, status)
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// reachedStateKey scopes a QuotaStatus transition to the caller's org/user,
+// not just its tag - quota.Tag encodes (service, target, scope-class), so
+// e.g. every org shares the same "dashboard:dashboard:org" tag. Without the
+// scope in the key, one org's status would flip the flag another org's
+// transition depends on.
+func reachedStateKey(scopeParams *quota.ScopeParameters, tag quota.Tag) string {
+	return scopeKey(scopeParams) + "|" + string(tag)
+}
+
+// publishTransition emits the bus events CheckQuotaStatus promises, each
+// edge-triggered per (scope, tag) so a caller only sees one event per
+// transition instead of one per request while sitting in the warning band
+// or at the limit.
+func (s *Service) publishTransition(ctx context.Context, targetSrv quota.TargetSrv, scopeParams *quota.ScopeParameters, status quota.QuotaStatus) {
+	if s.bus == nil {
+		return
+	}
+
+	key := reachedStateKey(scopeParams, status.Tag)
+
+	wasWarning, _ := s.warnState.Load(key)
+	s.warnState.Store(key, status.Warning)
+	if status.Warning && wasWarning != true {
+		if err := s.bus.Publish(ctx, &UsageWarningEvent{TargetSrv: targetSrv, Tag: status.Tag, Usage: status.Usage, Limit: status.Limit}); err != nil {
+			s.Logger.Warn("failed to publish quota usage warning event", "tag", status.Tag, "error", err)
+		}
+	}
+
+	wasReached, _ := s.reachedState.Load(key)
+	s.reachedState.Store(key, status.Reached)
+	if status.Reached && wasReached != true {
+		if err := s.bus.Publish(ctx, &ReachedEvent{TargetSrv: targetSrv, Tag: status.Tag, Usage: status.Usage, Limit: status.Limit}); err != nil {
+			s.Logger.Warn("failed to publish quota reached event", "tag", status.Tag, "error", err)
+		}
 	}
-	return false, nil
 }
 
 func (s *Service) DeleteByUser(ctx context.Context, userID int64) error {
@@ -214,6 +408,14 @@ func (s *Service) AddReporter(_ context.Context, e *quota.NewQuotaReporter) erro
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	// Enterprise-only reporters declare RequiresFeature; without a matching
+	// license this registration is refused rather than silently accepted,
+	// so an OSS build can't be tricked into reporting usage for a feature it
+	// doesn't have.
+	if e.RequiresFeature != "" && (s.license == nil || !s.license.HasFeature(e.RequiresFeature)) {
+		return quota.ErrInvalidTargetSrv.Errorf("target service %s requires unlicensed feature: %s", e.TargetSrv, e.RequiresFeature)
+	}
+
 	_, ok := s.reporters[e.TargetSrv]
 	if ok {
 		return quota.ErrTargetSrvConflict.Errorf("target service: %s already exists", e.TargetSrv)
@@ -222,6 +424,9 @@ func (s *Service) AddReporter(_ context.Context, e *quota.NewQuotaReporter) erro
 	s.reporters[e.TargetSrv] = e.Reporter
 
 	s.defaultLimits.Merge(e.DefaultLimits)
+	if e.WarnAt != nil {
+		s.warnAtLimits.Merge(e.WarnAt)
+	}
 
 	return nil
 }
@@ -254,6 +459,11 @@ func (s *Service) getReporters() <-chan reporter {
 	return ch
 }
 
+// getOverridenLimits resolves, for every tag belonging to targetSrv, the
+// limit that actually applies: the legacy per-scope custom override from
+// sqlStore.Get if one is set, otherwise the max limit across every quota
+// group assigned to scopeParams' user/org (-1 short-circuits as unlimited),
+// falling back to the reporter's default limit if neither applies.
 func (s *Service) getOverridenLimits(ctx context.Context, targetSrv quota.TargetSrv, scopeParams *quota.ScopeParameters) (map[quota.Tag]int64, error) {
 	targetSrvLimits := make(map[quota.Tag]int64)
 
@@ -262,6 +472,11 @@ func (s *Service) getOverridenLimits(ctx context.Context, targetSrv quota.Target
 		return targetSrvLimits, err
 	}
 
+	groupLimits, err := s.groupLimits(ctx, targetSrv, scopeParams)
+	if err != nil {
+		return targetSrvLimits, err
+	}
+
 	for item := range s.defaultLimits.Iter() {
 		srv, err := item.Tag.GetSrv()
 		if err != nil {
@@ -272,18 +487,201 @@ func (s *Service) getOverridenLimits(ctx context.Context, targetSrv quota.Target
 			continue
 		}
 
-		defaultLimit := item.Value
+		limit := item.Value
+
+		if groupLimit, ok := groupLimits[item.Tag]; ok {
+			limit = groupLimit
+		}
 
 		if customLimit, ok := customLimits.Get(item.Tag); ok {
-			targetSrvLimits[item.Tag] = customLimit
-		} else {
-			targetSrvLimits[item.Tag] = defaultLimit
+			limit = customLimit
 		}
+
+		targetSrvLimits[item.Tag] = limit
 	}
 
 	return targetSrvLimits, nil
 }
 
+// Reserve increments the cached usage counters for target by n and returns
+// quota.ErrQuotaReached if the post-increment value would exceed the limit
+// for any of the target's tags. Callers should do the increment-then-write
+// this guards around in a row; on failure call Release to give the
+// reservation back, on success call Commit.
+//
+// Unlike CheckQuotaReached, which reads usage and only later does the
+// insert, Reserve makes the check-and-increment atomic from the caller's
+// perspective, closing the race where two concurrent requests both observe
+// usage under the limit and both proceed.
+func (s *Service) Reserve(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	targetSrv := quota.TargetSrv(target)
+	targetSrvLimits, err := s.getOverridenLimits(ctx, targetSrv, scopeParams)
+	if err != nil {
+		return err
+	}
+
+	s.rememberScope(scopeParams)
+
+	reserved := make([]string, 0, len(targetSrvLimits))
+	for tag, limit := range targetSrvLimits {
+		if limit < 0 {
+			continue
+		}
+
+		key := counterKey(targetSrv, scopeParams, tag)
+		used, err := s.counters.incrBy(ctx, key, n)
+		if err != nil {
+			s.rollback(ctx, reserved, n)
+			return err
+		}
+		reserved = append(reserved, key)
+
+		if used > limit {
+			s.rollback(ctx, reserved, n)
+			return quota.ErrQuotaReached
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) rollback(ctx context.Context, keys []string, n int64) {
+	for _, key := range keys {
+		if _, err := s.counters.incrBy(ctx, key, -n); err != nil {
+			s.Logger.Warn("failed to roll back quota reservation", "key", key, "error", err)
+		}
+	}
+}
+
+// Release gives back a reservation made by Reserve, for example when the
+// write it guarded failed after the counter was incremented.
+func (s *Service) Release(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	return s.adjustReservation(ctx, target, scopeParams, -n)
+}
+
+// Commit is a no-op against the cached counters: Reserve's increment already
+// reflects the row once it's written. Commit exists so call sites can make
+// the reserve/commit/release lifecycle explicit at the point the write lands.
+func (s *Service) Commit(ctx context.Context, target string, scopeParams *quota.ScopeParameters, n int64) error {
+	return nil
+}
+
+func (s *Service) adjustReservation(ctx context.Context, target string, scopeParams *quota.ScopeParameters, delta int64) error {
+	targetSrv := quota.TargetSrv(target)
+	targetSrvLimits, err := s.getOverridenLimits(ctx, targetSrv, scopeParams)
+	if err != nil {
+		return err
+	}
+
+	s.rememberScope(scopeParams)
+
+	for tag := range targetSrvLimits {
+		if _, err := s.counters.incrBy(ctx, counterKey(targetSrv, scopeParams, tag), delta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run starts the background reconciler that keeps the cached counters honest
+// by periodically recomputing usage from each reporter's ground truth and
+// writing the result back into the counter store, and the reaper that frees
+// signup reservations abandoned past their TTL. It implements
+// registry.BackgroundService; either loop is skipped if its interval isn't
+// configured, so it can always be registered.
+func (s *Service) Run(ctx context.Context) error {
+	var reconcileTick, reapTick <-chan time.Time
+
+	if s.Cfg.Quota.ReconcileInterval > 0 {
+		ticker := time.NewTicker(s.Cfg.Quota.ReconcileInterval)
+		defer ticker.Stop()
+		reconcileTick = ticker.C
+	}
+
+	if s.Cfg.Quota.ReservationTTL > 0 {
+		ticker := time.NewTicker(s.Cfg.Quota.ReservationTTL)
+		defer ticker.Stop()
+		reapTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-reconcileTick:
+			if err := s.reconcile(ctx); err != nil {
+				s.Logger.Warn("quota reconciliation failed", "error", err)
+			}
+		case <-reapTick:
+			s.reapExpiredReservations(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// rememberScope records that scopeParams is a scope Reserve/Release has
+// actually been called against, so reconcile knows to reconcile it - without
+// this, reconcile would only ever true-up the global counter and every
+// per-org/per-user counter Reserve increments would drift from the
+// reporters' ground truth forever.
+func (s *Service) rememberScope(scopeParams *quota.ScopeParameters) {
+	if scopeParams == nil {
+		return
+	}
+	s.knownScopes.Store(scopeKey(scopeParams), scopeParams)
+}
+
+// reconcileScopes returns every scope reconcile should true-up for a target:
+// the global scope plus every scope Reserve/Release has touched.
+func (s *Service) reconcileScopes() []*quota.ScopeParameters {
+	scopes := []*quota.ScopeParameters{nil}
+	s.knownScopes.Range(func(_, v interface{}) bool {
+		scopes = append(scopes, v.(*quota.ScopeParameters))
+		return true
+	})
+	return scopes
+}
+
+// reconcile recomputes every registered reporter's usage, for the global
+// scope and every scope Reserve/Release has touched, and overwrites the
+// cached counters with it. Concurrent reconciliations for the same
+// (target service, scope) collapse into a single call via singleflight, so a
+// slow SQL COUNT(*) isn't duplicated across overlapping ticks.
+func (s *Service) reconcile(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	scopes := s.reconcileScopes()
+
+	for r := range s.getReporters() {
+		r := r
+		for _, scopeParams := range scopes {
+			scopeParams := scopeParams
+			g.Go(func() error {
+				dedupeKey := string(r.target) + ":" + scopeKey(scopeParams)
+				v, err, _ := s.reconcileGroup.Do(dedupeKey, func() (interface{}, error) {
+					return r.reporterFunc(ctx, scopeParams)
+				})
+				if err != nil {
+					return err
+				}
+
+				usage, ok := v.(*quota.Map)
+				if !ok {
+					return fmt.Errorf("unexpected usage type for target:%s", r.target)
+				}
+
+				for item := range usage.Iter() {
+					if err := s.counters.set(ctx, counterKey(r.target, scopeParams, item.Tag), item.Value); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	}
+
+	return g.Wait()
+}
+
 func (s *Service) getUsage(ctx context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
 	usage := &quota.Map{}
 	g, ctx := errgroup.WithContext(ctx)
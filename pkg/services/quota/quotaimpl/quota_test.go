@@ -0,0 +1,124 @@
+package quotaimpl
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// fakeBus is a minimal eventPublisher that records every published event so
+// tests can assert on edge-triggering without a real bus.
+type fakeBus struct {
+	mu     sync.Mutex
+	events []interface{}
+}
+
+func (b *fakeBus) Publish(_ context.Context, event interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+	return nil
+}
+
+func (b *fakeBus) count(match func(interface{}) bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, e := range b.events {
+		if match(e) {
+			n++
+		}
+	}
+	return n
+}
+
+func countReached(e interface{}) bool { _, ok := e.(*ReachedEvent); return ok }
+func countWarning(e interface{}) bool { _, ok := e.(*UsageWarningEvent); return ok }
+
+func TestService_CheckQuotaStatus_eventEdgeTriggering(t *testing.T) {
+	const srv = quota.TargetSrv("dashboard")
+	tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+
+	defaultLimits := &quota.Map{}
+	defaultLimits.Set(tag, 10)
+
+	warnAtLimits := &quota.Map{}
+	warnAtLimits.Set(tag, 80)
+
+	usage := int64(0)
+	reporter := quota.UsageReporterFunc(func(context.Context, *quota.ScopeParameters) (*quota.Map, error) {
+		m := &quota.Map{}
+		m.Set(tag, usage)
+		return m, nil
+	})
+
+	b := &fakeBus{}
+	s := &Service{
+		store:         &fakeStore{},
+		defaultLimits: defaultLimits,
+		warnAtLimits:  warnAtLimits,
+		reporters:     map[quota.TargetSrv]quota.UsageReporterFunc{srv: reporter},
+		bus:           b,
+	}
+
+	scopeParams := &quota.ScopeParameters{OrgID: 1}
+
+	usage = 5
+	_, err := s.CheckQuotaStatus(context.Background(), string(srv), scopeParams)
+	require.NoError(t, err)
+	require.Equal(t, 0, b.count(countWarning), "below warn threshold shouldn't warn")
+
+	usage = 8 // 80% of 10
+	_, err = s.CheckQuotaStatus(context.Background(), string(srv), scopeParams)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countWarning), "crossing the warn threshold should warn once")
+
+	_, err = s.CheckQuotaStatus(context.Background(), string(srv), scopeParams)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countWarning), "staying above the warn threshold shouldn't re-warn")
+
+	usage = 10
+	_, err = s.CheckQuotaStatus(context.Background(), string(srv), scopeParams)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countReached), "reaching the limit should fire ReachedEvent once")
+
+	_, err = s.CheckQuotaStatus(context.Background(), string(srv), scopeParams)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countReached), "staying at the limit shouldn't re-fire ReachedEvent")
+}
+
+func TestService_CheckQuotaStatus_eventsDontCrossOrgs(t *testing.T) {
+	const srv = quota.TargetSrv("dashboard")
+	tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+
+	defaultLimits := &quota.Map{}
+	defaultLimits.Set(tag, 10)
+
+	usageByOrg := map[int64]int64{1: 10, 2: 0}
+	reporter := quota.UsageReporterFunc(func(_ context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
+		m := &quota.Map{}
+		m.Set(tag, usageByOrg[scopeParams.OrgID])
+		return m, nil
+	})
+
+	b := &fakeBus{}
+	s := &Service{
+		store:         &fakeStore{},
+		defaultLimits: defaultLimits,
+		warnAtLimits:  &quota.Map{},
+		reporters:     map[quota.TargetSrv]quota.UsageReporterFunc{srv: reporter},
+		bus:           b,
+	}
+
+	_, err := s.CheckQuotaStatus(context.Background(), string(srv), &quota.ScopeParameters{OrgID: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countReached), "org 1 reaching its limit should fire its own ReachedEvent")
+
+	_, err = s.CheckQuotaStatus(context.Background(), string(srv), &quota.ScopeParameters{OrgID: 2})
+	require.NoError(t, err)
+	require.Equal(t, 1, b.count(countReached), "org 2 being under limit shouldn't be affected by org 1's reached state")
+}
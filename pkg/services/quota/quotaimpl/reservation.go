@@ -0,0 +1,93 @@
+package quotaimpl
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+const (
+	userTargetSrv    = quota.TargetSrv("user")
+	orgUserTargetSrv = quota.TargetSrv("org_user")
+)
+
+type signupReservation struct {
+	orgID     int64
+	expiresAt time.Time
+}
+
+// ReserveForNewUser takes a short-lived reservation against the user and
+// org_user quota tags before a new user row exists, so two concurrent SSO/
+// LDAP logins can't both pass a quota check, both insert, and land over the
+// limit. Call ConfirmReservation once userService.Create succeeds, or
+// CancelReservation if it doesn't; either way, an unconfirmed reservation is
+// freed by the reaper after Cfg.Quota.ReservationTTL.
+func (s *Service) ReserveForNewUser(ctx context.Context, orgID int64) (string, error) {
+	scopeParams := &quota.ScopeParameters{OrgID: orgID}
+
+	if err := s.Reserve(ctx, string(userTargetSrv), nil, 1); err != nil {
+		return "", err
+	}
+
+	if err := s.Reserve(ctx, string(orgUserTargetSrv), scopeParams, 1); err != nil {
+		if relErr := s.Release(ctx, string(userTargetSrv), nil, 1); relErr != nil {
+			s.Logger.Warn("failed to release user quota reservation after org_user reservation failed", "error", relErr)
+		}
+		return "", err
+	}
+
+	token := uuid.New().String()
+	s.signupReservations.Store(token, signupReservation{
+		orgID:     orgID,
+		expiresAt: time.Now().Add(s.Cfg.Quota.ReservationTTL),
+	})
+
+	return token, nil
+}
+
+// ConfirmReservation finalizes a reservation made by ReserveForNewUser once
+// the user row it guards has actually been written. The reserved counters
+// already reflect the new row, so this only forgets the reservation.
+func (s *Service) ConfirmReservation(_ context.Context, token string) error {
+	if _, ok := s.signupReservations.LoadAndDelete(token); !ok {
+		return quota.ErrInvalidReservationToken
+	}
+	return nil
+}
+
+// CancelReservation releases a reservation made by ReserveForNewUser, for
+// example when the login attempt that requested it failed for an unrelated
+// reason.
+func (s *Service) CancelReservation(ctx context.Context, token string) error {
+	v, ok := s.signupReservations.LoadAndDelete(token)
+	if !ok {
+		return quota.ErrInvalidReservationToken
+	}
+
+	res := v.(signupReservation)
+	if err := s.Release(ctx, string(userTargetSrv), nil, 1); err != nil {
+		return err
+	}
+	return s.Release(ctx, string(orgUserTargetSrv), &quota.ScopeParameters{OrgID: res.orgID}, 1)
+}
+
+// reapExpiredReservations releases any ReserveForNewUser reservation that
+// was never confirmed or cancelled, e.g. because the caller crashed
+// mid-login. It's driven from Run alongside the counter reconciler.
+func (s *Service) reapExpiredReservations(ctx context.Context) {
+	now := time.Now()
+
+	s.signupReservations.Range(func(key, value interface{}) bool {
+		if now.Before(value.(signupReservation).expiresAt) {
+			return true
+		}
+
+		token := key.(string)
+		if err := s.CancelReservation(ctx, token); err != nil {
+			s.Logger.Warn("failed to reap expired quota reservation", "token", token, "error", err)
+		}
+		return true
+	})
+}
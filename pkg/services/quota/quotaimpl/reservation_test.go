@@ -0,0 +1,249 @@
+package quotaimpl
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// fakeCounter is an in-memory quotaCounter for tests that don't need a real
+// cache; incrBy/set/get behave like counterStore's but against a plain map
+// instead of a remotecache.CacheStorage.
+type fakeCounter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func newFakeCounter() *fakeCounter {
+	return &fakeCounter{values: make(map[string]int64)}
+}
+
+func (c *fakeCounter) incrBy(_ context.Context, key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := c.values[key] + delta
+	if next < 0 {
+		next = 0
+	}
+	c.values[key] = next
+	return next, nil
+}
+
+func (c *fakeCounter) get(_ context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key], nil
+}
+
+func (c *fakeCounter) set(_ context.Context, key string, v int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = v
+	return nil
+}
+
+func TestService_Reserve(t *testing.T) {
+	const srv = quota.TargetSrv("dashboard")
+
+	t.Run("under every tag's limit succeeds and leaves counters incremented", func(t *testing.T) {
+		tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+		defaultLimits := &quota.Map{}
+		defaultLimits.Set(tag, 10)
+
+		counters := newFakeCounter()
+		s := &Service{store: &fakeStore{}, defaultLimits: defaultLimits, warnAtLimits: &quota.Map{}, counters: counters}
+
+		scopeParams := &quota.ScopeParameters{OrgID: 1}
+		require.NoError(t, s.Reserve(context.Background(), string(srv), scopeParams, 1))
+
+		used, err := counters.get(context.Background(), counterKey(srv, scopeParams, tag))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), used)
+	})
+
+	t.Run("exceeding a tag's limit rolls that tag's reservation back", func(t *testing.T) {
+		tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+		defaultLimits := &quota.Map{}
+		defaultLimits.Set(tag, 0) // any reservation exceeds this
+
+		counters := newFakeCounter()
+		s := &Service{store: &fakeStore{}, defaultLimits: defaultLimits, warnAtLimits: &quota.Map{}, counters: counters}
+
+		scopeParams := &quota.ScopeParameters{OrgID: 1}
+		err := s.Reserve(context.Background(), string(srv), scopeParams, 1)
+		require.ErrorIs(t, err, quota.ErrQuotaReached)
+
+		used, err := counters.get(context.Background(), counterKey(srv, scopeParams, tag))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), used, "the tag that tripped the limit should be rolled back")
+	})
+
+	t.Run("rollback reverts every key passed to it, not just the one that tripped the limit", func(t *testing.T) {
+		// Reserve builds this same list by appending each tag's key as it's
+		// reserved, in whatever order targetSrvLimits' map iteration yields -
+		// so rollback is exercised directly here, with an explicit key
+		// order, instead of depending on a randomized map order to put the
+		// "already reserved, still under its own limit" key before the one
+		// that trips the failure.
+		okKey := counterKey(srv, nil, mustTag(t, srv, "alert", quota.OrgScope))
+		overKey := counterKey(srv, nil, mustTag(t, srv, "dashboard", quota.OrgScope))
+
+		counters := newFakeCounter()
+		s := &Service{counters: counters}
+
+		_, err := counters.incrBy(context.Background(), okKey, 1)
+		require.NoError(t, err)
+		_, err = counters.incrBy(context.Background(), overKey, 1)
+		require.NoError(t, err)
+
+		s.rollback(context.Background(), []string{okKey, overKey}, 1)
+
+		okUsed, err := counters.get(context.Background(), okKey)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), okUsed, "a tag under its own limit should still be rolled back if a sibling tag failed")
+
+		overUsed, err := counters.get(context.Background(), overKey)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), overUsed, "the tag that tripped the limit should be rolled back too")
+	})
+
+	t.Run("Release gives a reservation back", func(t *testing.T) {
+		tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+		defaultLimits := &quota.Map{}
+		defaultLimits.Set(tag, 10)
+
+		counters := newFakeCounter()
+		s := &Service{store: &fakeStore{}, defaultLimits: defaultLimits, warnAtLimits: &quota.Map{}, counters: counters}
+
+		scopeParams := &quota.ScopeParameters{OrgID: 1}
+		require.NoError(t, s.Reserve(context.Background(), string(srv), scopeParams, 1))
+		require.NoError(t, s.Release(context.Background(), string(srv), scopeParams, 1))
+
+		used, err := counters.get(context.Background(), counterKey(srv, scopeParams, tag))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), used)
+	})
+}
+
+func newReservationTestService(ttl time.Duration) (*Service, *fakeCounter) {
+	defaultLimits := &quota.Map{}
+	defaultLimits.Set(mustTagForSrv(userTargetSrv), 10)
+	defaultLimits.Set(mustTagForSrv(orgUserTargetSrv), 10)
+
+	counters := newFakeCounter()
+	return &Service{
+		store:         &fakeStore{},
+		defaultLimits: defaultLimits,
+		warnAtLimits:  &quota.Map{},
+		counters:      counters,
+		Cfg:           &setting.Cfg{Quota: setting.QuotaSettings{ReservationTTL: ttl}},
+	}, counters
+}
+
+// mustTagForSrv builds the global-scope tag ReserveForNewUser's user/org_user
+// reservations are counted under; see reservation.go's userTargetSrv/
+// orgUserTargetSrv.
+func mustTagForSrv(srv quota.TargetSrv) quota.Tag {
+	tag, err := quota.NewTag(srv, quota.Target(srv), quota.GlobalScope)
+	if err != nil {
+		panic(err)
+	}
+	return tag
+}
+
+func TestService_ReserveForNewUser(t *testing.T) {
+	const orgID = int64(1)
+
+	t.Run("reserves both the user and org_user tags", func(t *testing.T) {
+		s, counters := newReservationTestService(time.Hour)
+
+		token, err := s.ReserveForNewUser(context.Background(), orgID)
+		require.NoError(t, err)
+		require.NotEmpty(t, token)
+
+		userUsed, err := counters.get(context.Background(), counterKey(userTargetSrv, nil, mustTagForSrv(userTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), userUsed)
+
+		orgUserUsed, err := counters.get(context.Background(), counterKey(orgUserTargetSrv, &quota.ScopeParameters{OrgID: orgID}, mustTagForSrv(orgUserTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), orgUserUsed)
+	})
+
+	t.Run("ConfirmReservation forgets the token without releasing the reservation", func(t *testing.T) {
+		s, counters := newReservationTestService(time.Hour)
+
+		token, err := s.ReserveForNewUser(context.Background(), orgID)
+		require.NoError(t, err)
+
+		require.NoError(t, s.ConfirmReservation(context.Background(), token))
+		require.ErrorIs(t, s.ConfirmReservation(context.Background(), token), quota.ErrInvalidReservationToken, "confirming twice should fail, the token is gone")
+
+		userUsed, err := counters.get(context.Background(), counterKey(userTargetSrv, nil, mustTagForSrv(userTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), userUsed, "a confirmed reservation's counters stay incremented - they now reflect the real row")
+	})
+
+	t.Run("CancelReservation releases both tags and forgets the token", func(t *testing.T) {
+		s, counters := newReservationTestService(time.Hour)
+
+		token, err := s.ReserveForNewUser(context.Background(), orgID)
+		require.NoError(t, err)
+
+		require.NoError(t, s.CancelReservation(context.Background(), token))
+		require.ErrorIs(t, s.CancelReservation(context.Background(), token), quota.ErrInvalidReservationToken, "cancelling twice should fail, the token is gone")
+
+		userUsed, err := counters.get(context.Background(), counterKey(userTargetSrv, nil, mustTagForSrv(userTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), userUsed)
+
+		orgUserUsed, err := counters.get(context.Background(), counterKey(orgUserTargetSrv, &quota.ScopeParameters{OrgID: orgID}, mustTagForSrv(orgUserTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), orgUserUsed)
+	})
+
+	t.Run("unknown token is rejected", func(t *testing.T) {
+		s, _ := newReservationTestService(time.Hour)
+		require.ErrorIs(t, s.ConfirmReservation(context.Background(), "does-not-exist"), quota.ErrInvalidReservationToken)
+		require.ErrorIs(t, s.CancelReservation(context.Background(), "does-not-exist"), quota.ErrInvalidReservationToken)
+	})
+}
+
+func TestService_reapExpiredReservations(t *testing.T) {
+	t.Run("reaps a reservation past its TTL, releasing both tags", func(t *testing.T) {
+		s, counters := newReservationTestService(time.Millisecond)
+
+		token, err := s.ReserveForNewUser(context.Background(), 1)
+		require.NoError(t, err)
+
+		time.Sleep(2 * time.Millisecond)
+		s.reapExpiredReservations(context.Background())
+
+		require.ErrorIs(t, s.CancelReservation(context.Background(), token), quota.ErrInvalidReservationToken, "a reaped token should already be gone")
+
+		userUsed, err := counters.get(context.Background(), counterKey(userTargetSrv, nil, mustTagForSrv(userTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), userUsed)
+	})
+
+	t.Run("leaves a reservation still inside its TTL alone", func(t *testing.T) {
+		s, counters := newReservationTestService(time.Hour)
+
+		token, err := s.ReserveForNewUser(context.Background(), 1)
+		require.NoError(t, err)
+
+		s.reapExpiredReservations(context.Background())
+
+		require.NoError(t, s.ConfirmReservation(context.Background(), token), "the reservation should still be live")
+
+		userUsed, err := counters.get(context.Background(), counterKey(userTargetSrv, nil, mustTagForSrv(userTargetSrv)))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), userUsed)
+	})
+}
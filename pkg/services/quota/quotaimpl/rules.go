@@ -0,0 +1,95 @@
+package quotaimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// CreateRule persists a new named rule that groups can reference.
+func (s *Service) CreateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return s.store.CreateRule(ctx, rule)
+}
+
+// UpdateRule changes the limit and/or subjects of an existing rule.
+func (s *Service) UpdateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return s.store.UpdateRule(ctx, rule)
+}
+
+// DeleteRule removes a rule. Groups that reference it by name keep the
+// reference, so recreating a rule with the same name restores it for them.
+func (s *Service) DeleteRule(ctx context.Context, name string) error {
+	return s.store.DeleteRule(ctx, name)
+}
+
+// CreateGroup creates a new, initially empty, quota group.
+func (s *Service) CreateGroup(ctx context.Context, name string) error {
+	return s.store.CreateGroup(ctx, &quota.QuotaGroup{Name: name})
+}
+
+// AddRuleToGroup attaches an existing rule to an existing group by name.
+func (s *Service) AddRuleToGroup(ctx context.Context, groupName, ruleName string) error {
+	return s.store.AddRuleToGroup(ctx, groupName, ruleName)
+}
+
+// AssignGroup attaches a quota group to a user or an org, replacing any
+// group previously assigned to that (kind, id) pair.
+func (s *Service) AssignGroup(ctx context.Context, kind quota.GroupMappingKind, id int64, groupName string) error {
+	return s.store.AssignGroup(ctx, &quota.QuotaGroupMapping{Kind: kind, MappedID: id, GroupName: groupName})
+}
+
+// groupLimits unions the limits of every group assigned to scopeParams'
+// user/org for tags belonging to targetSrv. Max wins across groups, and a
+// limit of -1 (unlimited) on any matching rule short-circuits the rest.
+func (s *Service) groupLimits(ctx context.Context, targetSrv quota.TargetSrv, scopeParams *quota.ScopeParameters) (map[quota.Tag]int64, error) {
+	limits := make(map[quota.Tag]int64)
+	if scopeParams == nil {
+		return limits, nil
+	}
+
+	var groups []*quota.QuotaGroup
+	if scopeParams.UserID != 0 {
+		userGroups, err := s.store.GetGroupsForMapping(ctx, quota.UserMappingKind, scopeParams.UserID)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, userGroups...)
+	}
+	if scopeParams.OrgID != 0 {
+		orgGroups, err := s.store.GetGroupsForMapping(ctx, quota.OrgMappingKind, scopeParams.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, orgGroups...)
+	}
+
+	for _, group := range groups {
+		rules, err := s.store.GetRulesForGroup(ctx, group.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			for _, tag := range rule.Subjects {
+				srv, err := tag.GetSrv()
+				if err != nil {
+					return nil, err
+				}
+				if srv != targetSrv {
+					continue
+				}
+
+				if rule.Limit < 0 {
+					limits[tag] = -1
+					continue
+				}
+
+				if cur, ok := limits[tag]; !ok || cur >= 0 && rule.Limit > cur {
+					limits[tag] = rule.Limit
+				}
+			}
+		}
+	}
+
+	return limits, nil
+}
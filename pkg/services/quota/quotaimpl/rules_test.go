@@ -0,0 +1,114 @@
+package quotaimpl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// fakeStore is a minimal in-memory store for tests that don't need a real
+// database; it implements the store interface a field at a time, returning
+// zero values for anything a given test doesn't populate.
+type fakeStore struct {
+	groupsByMapping map[string][]*quota.QuotaGroup
+	rulesByGroup    map[string][]*quota.QuotaRule
+}
+
+func (f *fakeStore) Get(context.Context, *quota.ScopeParameters) (*quota.Map, error) {
+	return &quota.Map{}, nil
+}
+func (f *fakeStore) Update(context.Context, *quota.UpdateQuotaCmd) error         { return nil }
+func (f *fakeStore) DeleteByUser(context.Context, int64) error                   { return nil }
+func (f *fakeStore) CreateRule(context.Context, *quota.QuotaRule) error          { return nil }
+func (f *fakeStore) UpdateRule(context.Context, *quota.QuotaRule) error          { return nil }
+func (f *fakeStore) DeleteRule(context.Context, string) error                    { return nil }
+func (f *fakeStore) CreateGroup(context.Context, *quota.QuotaGroup) error        { return nil }
+func (f *fakeStore) AddRuleToGroup(context.Context, string, string) error        { return nil }
+func (f *fakeStore) AssignGroup(context.Context, *quota.QuotaGroupMapping) error { return nil }
+
+func (f *fakeStore) GetGroupsForMapping(_ context.Context, kind quota.GroupMappingKind, mappedID int64) ([]*quota.QuotaGroup, error) {
+	return f.groupsByMapping[mappingKey(kind, mappedID)], nil
+}
+
+func (f *fakeStore) GetRulesForGroup(_ context.Context, groupName string) ([]*quota.QuotaRule, error) {
+	return f.rulesByGroup[groupName], nil
+}
+
+func mappingKey(kind quota.GroupMappingKind, mappedID int64) string {
+	return fmt.Sprintf("%s:%d", kind, mappedID)
+}
+
+func mustTag(t *testing.T, srv quota.TargetSrv, target quota.Target, scope quota.Scope) quota.Tag {
+	t.Helper()
+	tag, err := quota.NewTag(srv, target, scope)
+	require.NoError(t, err)
+	return tag
+}
+
+func TestService_groupLimits(t *testing.T) {
+	const srv = quota.TargetSrv("dashboard")
+
+	t.Run("no scope params means no group limits", func(t *testing.T) {
+		s := &Service{store: &fakeStore{}}
+		limits, err := s.groupLimits(context.Background(), srv, nil)
+		require.NoError(t, err)
+		require.Empty(t, limits)
+	})
+
+	t.Run("max wins across groups assigned to the same org", func(t *testing.T) {
+		tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+		store := &fakeStore{
+			groupsByMapping: map[string][]*quota.QuotaGroup{
+				mappingKey(quota.OrgMappingKind, 1): {{Name: "team"}, {Name: "free"}},
+			},
+			rulesByGroup: map[string][]*quota.QuotaRule{
+				"team": {{Name: "team-dashboards", Limit: 100, Subjects: []quota.Tag{tag}}},
+				"free": {{Name: "free-dashboards", Limit: 10, Subjects: []quota.Tag{tag}}},
+			},
+		}
+		s := &Service{store: store}
+
+		limits, err := s.groupLimits(context.Background(), srv, &quota.ScopeParameters{OrgID: 1})
+		require.NoError(t, err)
+		require.Equal(t, int64(100), limits[tag])
+	})
+
+	t.Run("any unlimited rule short-circuits to -1 regardless of order", func(t *testing.T) {
+		tag := mustTag(t, srv, "dashboard", quota.OrgScope)
+		store := &fakeStore{
+			groupsByMapping: map[string][]*quota.QuotaGroup{
+				mappingKey(quota.OrgMappingKind, 1): {{Name: "enterprise"}, {Name: "free"}},
+			},
+			rulesByGroup: map[string][]*quota.QuotaRule{
+				"enterprise": {{Name: "unlimited-dashboards", Limit: -1, Subjects: []quota.Tag{tag}}},
+				"free":       {{Name: "free-dashboards", Limit: 10, Subjects: []quota.Tag{tag}}},
+			},
+		}
+		s := &Service{store: store}
+
+		limits, err := s.groupLimits(context.Background(), srv, &quota.ScopeParameters{OrgID: 1})
+		require.NoError(t, err)
+		require.Equal(t, int64(-1), limits[tag])
+	})
+
+	t.Run("rules for other target services are ignored", func(t *testing.T) {
+		otherTag := mustTag(t, "apikey", "apikey", quota.OrgScope)
+		store := &fakeStore{
+			groupsByMapping: map[string][]*quota.QuotaGroup{
+				mappingKey(quota.OrgMappingKind, 1): {{Name: "team"}},
+			},
+			rulesByGroup: map[string][]*quota.QuotaRule{
+				"team": {{Name: "team-apikeys", Limit: 5, Subjects: []quota.Tag{otherTag}}},
+			},
+		}
+		s := &Service{store: store}
+
+		limits, err := s.groupLimits(context.Background(), srv, &quota.ScopeParameters{OrgID: 1})
+		require.NoError(t, err)
+		require.Empty(t, limits)
+	})
+}
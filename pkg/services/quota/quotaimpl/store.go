@@ -0,0 +1,252 @@
+package quotaimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/quota"
+)
+
+// store is the persistence boundary for quotaimpl: the long-standing
+// per-scope custom limit overrides, plus the rule/group entities tiered
+// quotas are built from.
+type store interface {
+	Get(ctx context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error)
+	Update(ctx context.Context, cmd *quota.UpdateQuotaCmd) error
+	DeleteByUser(ctx context.Context, userID int64) error
+
+	CreateRule(ctx context.Context, rule *quota.QuotaRule) error
+	UpdateRule(ctx context.Context, rule *quota.QuotaRule) error
+	DeleteRule(ctx context.Context, name string) error
+
+	CreateGroup(ctx context.Context, group *quota.QuotaGroup) error
+	AddRuleToGroup(ctx context.Context, groupName, ruleName string) error
+
+	AssignGroup(ctx context.Context, mapping *quota.QuotaGroupMapping) error
+	GetGroupsForMapping(ctx context.Context, kind quota.GroupMappingKind, mappedID int64) ([]*quota.QuotaGroup, error)
+	GetRulesForGroup(ctx context.Context, groupName string) ([]*quota.QuotaRule, error)
+}
+
+type sqlStore struct {
+	db db.DB
+}
+
+// quotaRow is the row shape of the long-standing "quota" table that backs
+// per-scope custom overrides.
+type quotaRow struct {
+	ID     int64  `xorm:"pk autoincr 'id'"`
+	OrgID  int64  `xorm:"org_id"`
+	UserID int64  `xorm:"user_id"`
+	Target string `xorm:"target"`
+	Limit  int64  `xorm:"limit"`
+}
+
+func (quotaRow) TableName() string { return "quota" }
+
+// quotaRuleSubject and quotaGroupRule are join rows for quota.QuotaRule's
+// Subjects and quota.QuotaGroup's Rules, which are xorm:"-" on their owning
+// structs.
+type quotaRuleSubject struct {
+	ID     int64  `xorm:"pk autoincr 'id'"`
+	RuleID int64  `xorm:"rule_id"`
+	Tag    string `xorm:"tag"`
+}
+
+func (quotaRuleSubject) TableName() string { return "quota_rule_subject" }
+
+type quotaGroupRule struct {
+	ID        int64  `xorm:"pk autoincr 'id'"`
+	GroupName string `xorm:"group_name"`
+	RuleName  string `xorm:"rule_name"`
+}
+
+func (quotaGroupRule) TableName() string { return "quota_group_rule" }
+
+func (s *sqlStore) Get(ctx context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
+	result := &quota.Map{}
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		rows := make([]quotaRow, 0)
+
+		var orgID, userID int64
+		if scopeParams != nil {
+			orgID, userID = scopeParams.OrgID, scopeParams.UserID
+		}
+
+		// Global rows, plus this org's org-level rows and this user's rows;
+		// overrides set for other orgs or users are not returned.
+		if err := sess.Where("(user_id = 0 AND (org_id = 0 OR org_id = ?)) OR (user_id <> 0 AND user_id = ?)", orgID, userID).Find(&rows); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			scope := quota.GlobalScope
+			switch {
+			case row.UserID != 0:
+				scope = quota.UserScope
+			case row.OrgID != 0:
+				scope = quota.OrgScope
+			}
+
+			tag, err := quota.NewTag(quota.TargetSrv(row.Target), quota.Target(row.Target), scope)
+			if err != nil {
+				return err
+			}
+			result.Set(tag, row.Limit)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *sqlStore) Update(ctx context.Context, cmd *quota.UpdateQuotaCmd) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		row := quotaRow{}
+		has, err := sess.Where("org_id = ? AND user_id = ? AND target = ?", cmd.OrgID, cmd.UserID, cmd.Target).Get(&row)
+		if err != nil {
+			return err
+		}
+
+		if !has {
+			row = quotaRow{OrgID: cmd.OrgID, UserID: cmd.UserID, Target: cmd.Target, Limit: cmd.Limit}
+			_, err := sess.Insert(&row)
+			return err
+		}
+
+		row.Limit = cmd.Limit
+		_, err = sess.ID(row.ID).Cols("limit").Update(&row)
+		return err
+	})
+}
+
+func (s *sqlStore) DeleteByUser(ctx context.Context, userID int64) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		_, err := sess.Where("user_id = ?", userID).Delete(&quotaRow{})
+		return err
+	})
+}
+
+func (s *sqlStore) CreateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		if _, err := sess.Insert(rule); err != nil {
+			return err
+		}
+		return s.setRuleSubjects(sess, rule)
+	})
+}
+
+func (s *sqlStore) UpdateRule(ctx context.Context, rule *quota.QuotaRule) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		if _, err := sess.ID(rule.ID).Cols("name", "limit").Update(rule); err != nil {
+			return err
+		}
+		if _, err := sess.Where("rule_id = ?", rule.ID).Delete(&quotaRuleSubject{}); err != nil {
+			return err
+		}
+		return s.setRuleSubjects(sess, rule)
+	})
+}
+
+func (s *sqlStore) setRuleSubjects(sess *db.Session, rule *quota.QuotaRule) error {
+	for _, tag := range rule.Subjects {
+		if _, err := sess.Insert(&quotaRuleSubject{RuleID: rule.ID, Tag: string(tag)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteRule(ctx context.Context, name string) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		_, err := sess.Where("name = ?", name).Delete(&quota.QuotaRule{})
+		return err
+	})
+}
+
+func (s *sqlStore) CreateGroup(ctx context.Context, group *quota.QuotaGroup) error {
+	return s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		_, err := sess.Insert(group)
+		return err
+	})
+}
+
+func (s *sqlStore) AddRuleToGroup(ctx context.Context, groupName, ruleName string) error {
+	return s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		_, err := sess.Insert(&quotaGroupRule{GroupName: groupName, RuleName: ruleName})
+		return err
+	})
+}
+
+func (s *sqlStore) AssignGroup(ctx context.Context, mapping *quota.QuotaGroupMapping) error {
+	return s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		if _, err := sess.Where("kind = ? AND mapped_id = ?", mapping.Kind, mapping.MappedID).Delete(&quota.QuotaGroupMapping{}); err != nil {
+			return err
+		}
+		_, err := sess.Insert(mapping)
+		return err
+	})
+}
+
+func (s *sqlStore) GetGroupsForMapping(ctx context.Context, kind quota.GroupMappingKind, mappedID int64) ([]*quota.QuotaGroup, error) {
+	groups := make([]*quota.QuotaGroup, 0)
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		mappings := make([]*quota.QuotaGroupMapping, 0)
+		if err := sess.Where("kind = ? AND mapped_id = ?", kind, mappedID).Find(&mappings); err != nil {
+			return err
+		}
+
+		for _, m := range mappings {
+			group := &quota.QuotaGroup{}
+			ok, err := sess.Where("name = ?", m.GroupName).Get(group)
+			if err != nil {
+				return err
+			}
+			if ok {
+				groups = append(groups, group)
+			}
+		}
+
+		return nil
+	})
+
+	return groups, err
+}
+
+func (s *sqlStore) GetRulesForGroup(ctx context.Context, groupName string) ([]*quota.QuotaRule, error) {
+	rules := make([]*quota.QuotaRule, 0)
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		groupRules := make([]*quotaGroupRule, 0)
+		if err := sess.Where("group_name = ?", groupName).Find(&groupRules); err != nil {
+			return err
+		}
+
+		for _, gr := range groupRules {
+			rule := &quota.QuotaRule{}
+			ok, err := sess.Where("name = ?", gr.RuleName).Get(rule)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			subjects := make([]*quotaRuleSubject, 0)
+			if err := sess.Where("rule_id = ?", rule.ID).Find(&subjects); err != nil {
+				return err
+			}
+			for _, subj := range subjects {
+				rule.Subjects = append(rule.Subjects, quota.Tag(subj.Tag))
+			}
+
+			rules = append(rules, rule)
+		}
+
+		return nil
+	})
+
+	return rules, err
+}
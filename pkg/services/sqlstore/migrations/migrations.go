@@ -0,0 +1,17 @@
+// Package migrations assembles every service's AddXMigrations registrations
+// into the single ordered list *sqlstore.SqlStore runs on startup. This file
+// carries only the quota migrations this tree has a call site for; a full
+// checkout has one AddXMigrations call per service here, in the order
+// they were first introduced.
+package migrations
+
+import (
+	"github.com/grafana/grafana/pkg/services/quota/quotaimpl"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// AddMigrations runs every service's migrations, in the order Grafana has
+// always applied them.
+func AddMigrations(mg *migrator.Migrator) {
+	quotaimpl.AddMigrations(mg)
+}
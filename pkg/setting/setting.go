@@ -0,0 +1,27 @@
+// Package setting defines Cfg, the parsed configuration tree services pull
+// their settings from. This file carries only the subset quotaimpl depends
+// on; the rest of Grafana's settings live alongside it in a full checkout.
+package setting
+
+import "time"
+
+// Cfg is the root configuration struct passed to ProvideService functions
+// across Grafana.
+type Cfg struct {
+	Quota QuotaSettings
+}
+
+// QuotaSettings is the [quota] config section.
+type QuotaSettings struct {
+	Enabled bool
+
+	// ReconcileInterval is how often quotaimpl's background reconciler
+	// walks every reporter and writes its usage back into the cached
+	// counters Reserve/Release/Commit depend on. Zero disables the loop.
+	ReconcileInterval time.Duration
+
+	// ReservationTTL is how long a ReserveForNewUser reservation can sit
+	// unconfirmed before reapExpiredReservations frees it. Zero disables
+	// the reaper.
+	ReservationTTL time.Duration
+}